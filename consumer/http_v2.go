@@ -13,18 +13,29 @@ import (
 // This object is not thread safe
 type V2HTTPMockProvider struct {
 	*httpMockProvider
+	stateHandlers *stateHandlerServer
 }
 
 // NewV2Pact configures a new V2 HTTP Mock Provider for consumer tests
 func NewV2Pact(config MockHTTPProviderConfig) (*V2HTTPMockProvider, error) {
+	stateHandlers, err := newStateHandlerServer(config.StateHandlers)
+	if err != nil {
+		return nil, err
+	}
+
 	provider := &V2HTTPMockProvider{
 		httpMockProvider: &httpMockProvider{
 			config:               config,
 			specificationVersion: models.V2,
 		},
+		stateHandlers: stateHandlers,
+	}
+
+	if stateHandlers != nil {
+		provider.httpMockProvider.withStateChangeURL(stateHandlers.URL())
 	}
-	err := provider.configure()
 
+	err = provider.configure()
 	if err != nil {
 		return nil, err
 	}
@@ -60,6 +71,17 @@ func (i *UnconfiguredV2Interaction) Given(state string) *UnconfiguredV2Interacti
 	return i
 }
 
+// GivenWithParameter specifies a provider state along with the params the
+// mock server's state change callback (see MockHTTPProviderConfig.StateHandlers)
+// is invoked with, so a StateHandlerFunc can look up/generate the right
+// values for this particular interaction (e.g. which user id "User foo
+// exists" should use).
+func (i *UnconfiguredV2Interaction) GivenWithParameter(state string, params map[string]interface{}) *UnconfiguredV2Interaction {
+	i.interaction.interaction.GivenWithParameter(state, params)
+
+	return i
+}
+
 type V2InteractionWithRequest struct {
 	interaction *Interaction
 	provider    *V2HTTPMockProvider
@@ -68,8 +90,9 @@ type V2InteractionWithRequest struct {
 type V2RequestBuilder func(*V2InteractionWithRequestBuilder)
 
 type V2InteractionWithRequestBuilder struct {
-	interaction *Interaction
-	provider    *V2HTTPMockProvider
+	interaction   *Interaction
+	provider      *V2HTTPMockProvider
+	matchingRules map[string]matchers.Matcher
 }
 
 // UponReceiving specifies the name of the test case. This becomes the name of
@@ -208,6 +231,28 @@ func (i *V2InteractionWithRequestBuilder) BodyMatch(body interface{}) *V2Interac
 	return i
 }
 
+// MatchingRules attaches matching rules to the expected request body by
+// path expression (e.g. "$.items[*].id"), rather than by wrapping values
+// in the body itself with Like/Regex/etc. This can be called alongside
+// JSONBody/BodyMatch to keep an example fixture as plain JSON while still
+// expressing matching intent. The rules are merged only with those from
+// earlier MatchingRules calls on the same builder value, where a later call
+// overrides an earlier one for the same path; matchers written inline in
+// the body are not read into that merge.
+func (i *V2InteractionWithRequestBuilder) MatchingRules(rules ...matchers.PathRule) *V2InteractionWithRequestBuilder {
+	merged, err := matchers.MergeMatchingRules(i.matchingRules, rules)
+	if err != nil {
+		panic(err)
+	}
+	i.matchingRules = merged
+
+	if err := i.interaction.interaction.WithRequestMatchingRules(matchers.RulesFromMatchingRules(merged)...); err != nil {
+		panic(err)
+	}
+
+	return i
+}
+
 // WillRespondWith sets the expected status and provides a response builder
 func (i *V2InteractionWithRequest) WillRespondWith(status int, builders ...V2ResponseBuilder) *V2InteractionWithResponse {
 	i.interaction.interaction.WithStatus(status)
@@ -229,8 +274,9 @@ func (i *V2InteractionWithRequest) WillRespondWith(status int, builders ...V2Res
 type V2ResponseBuilder func(*V2InteractionWithResponseBuilder)
 
 type V2InteractionWithResponseBuilder struct {
-	interaction *Interaction
-	provider    *V2HTTPMockProvider
+	interaction   *Interaction
+	provider      *V2HTTPMockProvider
+	matchingRules map[string]matchers.Matcher
 }
 
 type V2InteractionWithResponse struct {
@@ -302,7 +348,33 @@ func (i *V2InteractionWithResponseBuilder) BodyMatch(body interface{}) *V2Intera
 	return i
 }
 
+// MatchingRules attaches matching rules to the expected response body by
+// path expression - see V2InteractionWithRequestBuilder.MatchingRules for
+// the supported path syntax and override semantics.
+func (i *V2InteractionWithResponseBuilder) MatchingRules(rules ...matchers.PathRule) *V2InteractionWithResponseBuilder {
+	merged, err := matchers.MergeMatchingRules(i.matchingRules, rules)
+	if err != nil {
+		panic(err)
+	}
+	i.matchingRules = merged
+
+	if err := i.interaction.interaction.WithResponseMatchingRules(matchers.RulesFromMatchingRules(merged)...); err != nil {
+		panic(err)
+	}
+
+	return i
+}
+
 // ExecuteTest runs the current test case against a Mock Service.
 func (m *V2InteractionWithResponse) ExecuteTest(t *testing.T, integrationTest func(MockServerConfig) error) error {
 	return m.provider.ExecuteTest(t, integrationTest)
 }
+
+// ExecuteTest runs integrationTest against the mock server, then stops the
+// provider state callback server (if one was configured via
+// MockHTTPProviderConfig.StateHandlers) once the test has finished.
+func (p *V2HTTPMockProvider) ExecuteTest(t *testing.T, integrationTest func(MockServerConfig) error) error {
+	defer p.stateHandlers.Close()
+
+	return p.httpMockProvider.ExecuteTest(t, integrationTest)
+}
@@ -0,0 +1,458 @@
+package consumer
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"testing"
+
+	"github.com/pact-foundation/pact-go/v2/matchers"
+	"github.com/pact-foundation/pact-go/v2/models"
+)
+
+// V4HTTPMockProvider is the entrypoint for V4 http consumer tests. In
+// addition to everything V2/V3 interactions support, V4 interactions may
+// be backed by a Pact plugin (see PluginConfig on MockHTTPProviderConfig)
+// to express content types such as Protobuf, gRPC or Avro.
+// This object is not thread safe
+type V4HTTPMockProvider struct {
+	*httpMockProvider
+	plugins       *pluginManager
+	stateHandlers *stateHandlerServer
+}
+
+// NewV4Pact configures a new V4 HTTP Mock Provider for consumer tests
+func NewV4Pact(config MockHTTPProviderConfig) (*V4HTTPMockProvider, error) {
+	stateHandlers, err := newStateHandlerServer(config.StateHandlers)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := &V4HTTPMockProvider{
+		httpMockProvider: &httpMockProvider{
+			config:               config,
+			specificationVersion: models.V4,
+		},
+		plugins:       newPluginManager(),
+		stateHandlers: stateHandlers,
+	}
+
+	if stateHandlers != nil {
+		provider.httpMockProvider.withStateChangeURL(stateHandlers.URL())
+	}
+
+	for _, p := range config.Plugins {
+		if _, err := provider.plugins.ensurePlugin(p); err != nil {
+			return nil, err
+		}
+	}
+
+	err = provider.configure()
+	if err != nil {
+		return nil, err
+	}
+
+	return provider, err
+}
+
+// AddInteraction to the pact
+func (p *V4HTTPMockProvider) AddInteraction() *UnconfiguredV4Interaction {
+	log.Println("[DEBUG] pact add V4 interaction")
+	interaction := p.httpMockProvider.mockserver.NewInteraction("")
+
+	i := &UnconfiguredV4Interaction{
+		interaction: &Interaction{
+			specificationVersion: models.V4,
+			interaction:          interaction,
+		},
+		provider: p,
+	}
+
+	return i
+}
+
+type UnconfiguredV4Interaction struct {
+	interaction *Interaction
+	provider    *V4HTTPMockProvider
+}
+
+// Given specifies a provider state, may be called multiple times. Optional.
+func (i *UnconfiguredV4Interaction) Given(state string) *UnconfiguredV4Interaction {
+	i.interaction.interaction.Given(state)
+
+	return i
+}
+
+// GivenWithParameter specifies a provider state along with the params the
+// mock server's state change callback (see MockHTTPProviderConfig.StateHandlers)
+// is invoked with - see UnconfiguredV2Interaction.GivenWithParameter for
+// details.
+func (i *UnconfiguredV4Interaction) GivenWithParameter(state string, params map[string]interface{}) *UnconfiguredV4Interaction {
+	i.interaction.interaction.GivenWithParameter(state, params)
+
+	return i
+}
+
+// UponReceiving specifies the name of the test case. This becomes the name of
+// the consumer/provider pair in the Pact file. Mandatory.
+func (i *UnconfiguredV4Interaction) UponReceiving(description string) *UnconfiguredV4Interaction {
+	i.interaction.interaction.UponReceiving(description)
+
+	return i
+}
+
+// WithRequest provides a builder for the expected request
+func (i *UnconfiguredV4Interaction) WithRequest(method Method, path string, builders ...V4RequestBuilder) *V4InteractionWithRequest {
+	return i.WithRequestPathMatcher(method, matchers.String(path), builders...)
+}
+
+// WithRequestPathMatcher allows a matcher in the expected request path
+func (i *UnconfiguredV4Interaction) WithRequestPathMatcher(method Method, path matchers.Matcher, builders ...V4RequestBuilder) *V4InteractionWithRequest {
+	i.interaction.interaction.WithRequest(string(method), path)
+
+	for _, builder := range builders {
+		builder(&V4InteractionWithRequestBuilder{
+			interaction: i.interaction,
+			provider:    i.provider,
+		})
+	}
+
+	return &V4InteractionWithRequest{
+		interaction: i.interaction,
+		provider:    i.provider,
+	}
+}
+
+type V4RequestBuilder func(*V4InteractionWithRequestBuilder)
+
+type V4InteractionWithRequestBuilder struct {
+	interaction   *Interaction
+	provider      *V4HTTPMockProvider
+	matchingRules map[string]matchers.Matcher
+}
+
+type V4InteractionWithRequest struct {
+	interaction *Interaction
+	provider    *V4HTTPMockProvider
+}
+
+// Query specifies any query string on the expect request
+func (i *V4InteractionWithRequestBuilder) Query(key string, values ...matchers.Matcher) *V4InteractionWithRequestBuilder {
+	i.interaction.interaction.WithQuery(keyValuesToMapStringArrayInterface(key, values...))
+
+	return i
+}
+
+// Headers sets the headers on the expected request
+func (i *V4InteractionWithRequestBuilder) Headers(headers matchers.HeadersMatcher) *V4InteractionWithRequestBuilder {
+	i.interaction.interaction.WithRequestHeaders(headersMatcherToNativeHeaders(headers))
+
+	return i
+}
+
+// Header adds a header to the expected request
+func (i *V4InteractionWithRequestBuilder) Header(key string, values ...matchers.Matcher) *V4InteractionWithRequestBuilder {
+	i.interaction.interaction.WithRequestHeaders(keyValuesToMapStringArrayInterface(key, values...))
+
+	return i
+}
+
+// JSONBody adds a JSON body to the expected request
+func (i *V4InteractionWithRequestBuilder) JSONBody(body interface{}) *V4InteractionWithRequestBuilder {
+	if err := validateMatchers(i.interaction.specificationVersion, body); err != nil {
+		panic(err)
+	}
+
+	i.interaction.interaction.WithJSONRequestBody(body)
+
+	return i
+}
+
+// BinaryBody adds a binary body to the expected request
+func (i *V4InteractionWithRequestBuilder) BinaryBody(body []byte) *V4InteractionWithRequestBuilder {
+	i.interaction.interaction.WithBinaryRequestBody(body)
+
+	return i
+}
+
+// MultipartBody adds a multipart body to the expected request
+func (i *V4InteractionWithRequestBuilder) MultipartBody(contentType string, filename string, mimePartName string) *V4InteractionWithRequestBuilder {
+	i.interaction.interaction.WithRequestMultipartFile(contentType, filename, mimePartName)
+
+	return i
+}
+
+// Body adds a general body to the expected request
+func (i *V4InteractionWithRequestBuilder) Body(contentType string, body []byte) *V4InteractionWithRequestBuilder {
+	i.interaction.interaction.WithRequestBody(contentType, body)
+
+	return i
+}
+
+// BodyMatch uses struct tags to automatically determine matchers from the given struct
+func (i *V4InteractionWithRequestBuilder) BodyMatch(body interface{}) *V4InteractionWithRequestBuilder {
+	i.interaction.interaction.WithJSONRequestBody(matchers.MatchV2(body))
+
+	return i
+}
+
+// MatchingRules attaches matching rules to the expected request body by
+// path expression - see V2InteractionWithRequestBuilder.MatchingRules for
+// the supported path syntax and override semantics.
+func (i *V4InteractionWithRequestBuilder) MatchingRules(rules ...matchers.PathRule) *V4InteractionWithRequestBuilder {
+	merged, err := matchers.MergeMatchingRules(i.matchingRules, rules)
+	if err != nil {
+		panic(err)
+	}
+	i.matchingRules = merged
+
+	if err := i.interaction.interaction.WithRequestMatchingRules(matchers.RulesFromMatchingRules(merged)...); err != nil {
+		panic(err)
+	}
+
+	return i
+}
+
+// StreamBody registers an expected request body read from r rather than
+// held entirely in memory up front - see
+// V2InteractionWithRequestBuilder.StreamBody for the chunking/sidecar
+// behaviour, which applies identically here.
+func (i *V4InteractionWithRequestBuilder) StreamBody(contentType string, r io.Reader, opts ...StreamBodyOption) *V4InteractionWithRequestBuilder {
+	body, sidecar, err := bufferStreamedBody(r, newStreamBodyConfig(opts...))
+	if err != nil {
+		panic(fmt.Errorf("unable to read streamed request body: %w", err))
+	}
+
+	if sidecar != "" {
+		i.interaction.interaction.WithRequestBodySidecar(contentType, sidecar)
+	} else {
+		i.interaction.interaction.WithRequestBody(contentType, body)
+	}
+
+	return i
+}
+
+// StreamBodyMatch is the NDJSON-aware variant of StreamBody - see
+// V2InteractionWithRequestBuilder.StreamBodyMatch for the line-by-line
+// comparison semantics, which apply identically here.
+func (i *V4InteractionWithRequestBuilder) StreamBodyMatch(contentType string, r io.Reader, opts ...StreamBodyOption) *V4InteractionWithRequestBuilder {
+	c := newStreamBodyConfig(opts...)
+	c.matchLineByLine = true
+
+	body, sidecar, err := bufferStreamedBody(r, c)
+	if err != nil {
+		panic(fmt.Errorf("unable to read streamed request body: %w", err))
+	}
+
+	if sidecar != "" {
+		i.interaction.interaction.WithRequestBodySidecarLineMatch(contentType, sidecar)
+	} else {
+		i.interaction.interaction.WithRequestBodyLineMatch(contentType, body)
+	}
+
+	return i
+}
+
+// WithPluginRequestContent routes the supplied contents through the named
+// plugin's ContentMatcher/ContentGenerator (via ConfigureInteraction) to
+// obtain the actual request body bytes, matching rules and generators, and
+// attaches them to the interaction. contentType selects which registered
+// plugin handles the content (e.g. "application/protobuf").
+func (i *V4InteractionWithRequestBuilder) WithPluginRequestContent(contentType string, contents interface{}) *V4InteractionWithRequestBuilder {
+	res, err := i.provider.plugins.configureInteraction(pluginNameForContentType(i.provider.plugins, contentType), configureInteractionRequest{
+		ContentType: contentType,
+		Contents:    contents,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	i.interaction.interaction.WithRequestBody(res.ContentType, res.Contents)
+	i.interaction.interaction.WithPluginConfiguration(res.Rules, res.Generators, res.InteractionMarkup)
+
+	return i
+}
+
+// WillRespondWith sets the expected status and provides a response builder
+func (i *V4InteractionWithRequest) WillRespondWith(status int, builders ...V4ResponseBuilder) *V4InteractionWithResponse {
+	i.interaction.interaction.WithStatus(status)
+
+	for _, builder := range builders {
+		builder(&V4InteractionWithResponseBuilder{
+			interaction: i.interaction,
+			provider:    i.provider,
+		})
+	}
+
+	return &V4InteractionWithResponse{
+		interaction: i.interaction,
+		provider:    i.provider,
+	}
+}
+
+type V4ResponseBuilder func(*V4InteractionWithResponseBuilder)
+
+type V4InteractionWithResponseBuilder struct {
+	interaction   *Interaction
+	provider      *V4HTTPMockProvider
+	matchingRules map[string]matchers.Matcher
+}
+
+type V4InteractionWithResponse struct {
+	interaction *Interaction
+	provider    *V4HTTPMockProvider
+}
+
+// Headers sets the headers on the expected response
+func (i *V4InteractionWithResponseBuilder) Headers(headers matchers.HeadersMatcher) *V4InteractionWithResponseBuilder {
+	i.interaction.interaction.WithResponseHeaders(headersMatcherToNativeHeaders(headers))
+
+	return i
+}
+
+// JSONBody adds a JSON body to the expected response
+func (i *V4InteractionWithResponseBuilder) JSONBody(body interface{}) *V4InteractionWithResponseBuilder {
+	if err := validateMatchers(i.interaction.specificationVersion, body); err != nil {
+		panic(err)
+	}
+
+	i.interaction.interaction.WithJSONResponseBody(body)
+
+	return i
+}
+
+// BinaryBody adds a binary body to the expected response
+func (i *V4InteractionWithResponseBuilder) BinaryBody(body []byte) *V4InteractionWithResponseBuilder {
+	i.interaction.interaction.WithBinaryResponseBody(body)
+
+	return i
+}
+
+// MultipartBody adds a multipart body to the expected response
+func (i *V4InteractionWithResponseBuilder) MultipartBody(contentType string, filename string, mimePartName string) *V4InteractionWithResponseBuilder {
+	i.interaction.interaction.WithResponseMultipartFile(contentType, filename, mimePartName)
+
+	return i
+}
+
+// Body adds a general body to the expected response
+func (i *V4InteractionWithResponseBuilder) Body(contentType string, body []byte) *V4InteractionWithResponseBuilder {
+	i.interaction.interaction.WithResponseBody(contentType, body)
+
+	return i
+}
+
+// BodyMatch uses struct tags to automatically determine matchers from the given struct
+func (i *V4InteractionWithResponseBuilder) BodyMatch(body interface{}) *V4InteractionWithResponseBuilder {
+	i.interaction.interaction.WithJSONResponseBody(matchers.MatchV2(body))
+
+	return i
+}
+
+// MatchingRules attaches matching rules to the expected response body by
+// path expression - see V2InteractionWithRequestBuilder.MatchingRules for
+// the supported path syntax and override semantics.
+func (i *V4InteractionWithResponseBuilder) MatchingRules(rules ...matchers.PathRule) *V4InteractionWithResponseBuilder {
+	merged, err := matchers.MergeMatchingRules(i.matchingRules, rules)
+	if err != nil {
+		panic(err)
+	}
+	i.matchingRules = merged
+
+	if err := i.interaction.interaction.WithResponseMatchingRules(matchers.RulesFromMatchingRules(merged)...); err != nil {
+		panic(err)
+	}
+
+	return i
+}
+
+// StreamBody describes a response body read from r rather than held
+// entirely in memory up front by the caller - see
+// V2InteractionWithResponseBuilder.StreamBody for the sidecar behaviour
+// and the native/Go scope split.
+func (i *V4InteractionWithResponseBuilder) StreamBody(contentType string, r io.Reader, opts ...StreamBodyOption) *V4InteractionWithResponseBuilder {
+	body, sidecar, err := bufferStreamedBody(r, newStreamBodyConfig(opts...))
+	if err != nil {
+		panic(fmt.Errorf("unable to read streamed response body: %w", err))
+	}
+
+	if sidecar != "" {
+		i.interaction.interaction.WithResponseBodySidecar(contentType, sidecar)
+	} else {
+		i.interaction.interaction.WithResponseBody(contentType, body)
+	}
+
+	return i
+}
+
+// StreamBodyMatch is the NDJSON-aware variant of StreamBody for responses -
+// see V2InteractionWithRequestBuilder.StreamBodyMatch for the line-by-line
+// comparison semantics.
+func (i *V4InteractionWithResponseBuilder) StreamBodyMatch(contentType string, r io.Reader, opts ...StreamBodyOption) *V4InteractionWithResponseBuilder {
+	c := newStreamBodyConfig(opts...)
+	c.matchLineByLine = true
+
+	body, sidecar, err := bufferStreamedBody(r, c)
+	if err != nil {
+		panic(fmt.Errorf("unable to read streamed response body: %w", err))
+	}
+
+	if sidecar != "" {
+		i.interaction.interaction.WithResponseBodySidecarLineMatch(contentType, sidecar)
+	} else {
+		i.interaction.interaction.WithResponseBodyLineMatch(contentType, body)
+	}
+
+	return i
+}
+
+// WithPluginResponseContent is the response-side equivalent of
+// WithPluginRequestContent - see its docs for details.
+func (i *V4InteractionWithResponseBuilder) WithPluginResponseContent(contentType string, contents interface{}) *V4InteractionWithResponseBuilder {
+	res, err := i.provider.plugins.configureInteraction(pluginNameForContentType(i.provider.plugins, contentType), configureInteractionRequest{
+		ContentType: contentType,
+		Contents:    contents,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	i.interaction.interaction.WithResponseBody(res.ContentType, res.Contents)
+	i.interaction.interaction.WithPluginConfiguration(res.Rules, res.Generators, res.InteractionMarkup)
+
+	return i
+}
+
+// ExecuteTest runs the current test case against a Mock Service.
+func (m *V4InteractionWithResponse) ExecuteTest(t *testing.T, integrationTest func(MockServerConfig) error) error {
+	return m.provider.ExecuteTest(t, integrationTest)
+}
+
+// ExecuteTest runs integrationTest against the mock server, then shuts down
+// any plugins and the provider state callback server (if configured via
+// MockHTTPProviderConfig.StateHandlers) once the test has finished.
+func (p *V4HTTPMockProvider) ExecuteTest(t *testing.T, integrationTest func(MockServerConfig) error) error {
+	defer p.plugins.shutdown()
+	defer p.stateHandlers.Close()
+
+	return p.httpMockProvider.ExecuteTest(t, integrationTest)
+}
+
+// pluginNameForContentType finds which registered plugin's catalogue
+// advertises support for the given content type. Pact plugins publish
+// their supported content types as part of the InitPlugin handshake, so
+// by the time an interaction is being built the catalogue is already
+// populated. Each catalogue entry is tagged with the plugin that
+// registered it, so with multiple plugins configured a content type is
+// only ever routed to the plugin that actually advertised it.
+func pluginNameForContentType(m *pluginManager, contentType string) string {
+	for _, entry := range m.catalogue {
+		for _, ct := range entry.contentTypes {
+			if ct == contentType {
+				return entry.plugin
+			}
+		}
+	}
+
+	return ""
+}
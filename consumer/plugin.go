@@ -0,0 +1,398 @@
+package consumer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// PluginConfig describes a Pact plugin that should be loaded for the
+// duration of a V4 interaction (e.g. protobuf, gRPC, Avro). Plugins are
+// started as a separate process and communicate with pact-go over the
+// shared Pact plugin gRPC protocol (see pact-plugins/plugin.proto).
+type PluginConfig struct {
+	// Name is the plugin's registered name (e.g. "protobuf", "grpc").
+	Name string
+
+	// Version is the semver version of the plugin to load.
+	Version string
+
+	// DownloadURL is an optional plugin download location. It is not read
+	// when a plugin is started: startPluginProcess only launches plugins
+	// already installed under pluginInstallDir, and nothing is downloaded.
+	DownloadURL string
+}
+
+// pluginCatalogueEntry is a single entry published by a plugin during
+// initialisation, describing a content type it can match or generate.
+// plugin records which configured plugin registered the entry, so that a
+// content type advertised by one plugin is never routed to another.
+type pluginCatalogueEntry struct {
+	plugin       string
+	entryType    string // "CONTENT_MATCHER" or "CONTENT_GENERATOR"
+	key          string
+	contentTypes []string
+}
+
+// pluginManager tracks the plugins that have been started for a given
+// mock provider, and the catalogue entries they have registered. A single
+// manager is shared across all interactions added to a V4HTTPMockProvider
+// so that a plugin is only ever started once per pact.
+type pluginManager struct {
+	plugins   map[string]*pluginProcess
+	catalogue []pluginCatalogueEntry
+}
+
+func newPluginManager() *pluginManager {
+	return &pluginManager{
+		plugins: make(map[string]*pluginProcess),
+	}
+}
+
+// pluginProcess represents a running plugin process and the gRPC
+// connection used to talk to it.
+type pluginProcess struct {
+	config PluginConfig
+	addr   string
+	cmd    *exec.Cmd
+	conn   *grpc.ClientConn
+}
+
+// initPluginRequest is sent to a freshly started plugin process so it can
+// report its catalogue of content matchers/generators.
+type initPluginRequest struct {
+	ImplementationName    string
+	ImplementationVersion string
+}
+
+// initPluginResponse is the plugin's reply to initPluginRequest.
+type initPluginResponse struct {
+	Catalogue []pluginCatalogueEntry
+}
+
+// configureInteractionRequest asks a plugin to turn a user-supplied
+// description of some content (e.g. a protobuf message name plus field
+// values) into the actual body bytes, matching rules and generators that
+// should be attached to an interaction.
+type configureInteractionRequest struct {
+	ContentType string
+	Contents    interface{}
+}
+
+// configureInteractionResponse is the plugin's reply to
+// configureInteractionRequest.
+type configureInteractionResponse struct {
+	Contents          []byte
+	ContentType       string
+	Rules             map[string]interface{}
+	Generators        map[string]interface{}
+	InteractionMarkup string
+}
+
+// ensurePlugin starts the plugin process described by config (if it is not
+// already running for this provider) and performs the InitPlugin
+// handshake, merging any catalogue entries it registers into the manager.
+func (m *pluginManager) ensurePlugin(config PluginConfig) (*pluginProcess, error) {
+	if p, ok := m.plugins[config.Name]; ok {
+		return p, nil
+	}
+
+	p, err := startPluginProcess(config)
+	if err != nil {
+		return nil, fmt.Errorf("unable to start plugin %s: %w", config.Name, err)
+	}
+
+	res, err := p.initPlugin(initPluginRequest{
+		ImplementationName:    "pact-go",
+		ImplementationVersion: "2.x",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s failed to initialise: %w", config.Name, err)
+	}
+
+	for i := range res.Catalogue {
+		res.Catalogue[i].plugin = config.Name
+	}
+
+	m.plugins[config.Name] = p
+	m.catalogue = append(m.catalogue, res.Catalogue...)
+
+	return p, nil
+}
+
+// configureInteraction asks the named plugin to build the body, rules and
+// generators for some plugin-described content.
+func (m *pluginManager) configureInteraction(pluginName string, req configureInteractionRequest) (*configureInteractionResponse, error) {
+	p, ok := m.plugins[pluginName]
+	if !ok {
+		return nil, fmt.Errorf("plugin %s has not been started for this pact", pluginName)
+	}
+
+	return p.configureInteraction(req)
+}
+
+// shutdown stops all plugin processes started by this manager. It is
+// called once the mock provider's test has finished executing.
+func (m *pluginManager) shutdown() {
+	for name, p := range m.plugins {
+		p.stop()
+		delete(m.plugins, name)
+	}
+}
+
+// pluginHandshake is the single line of JSON a plugin writes to stdout once
+// it is ready to accept connections, per the Pact plugin startup protocol.
+type pluginHandshake struct {
+	Port      int    `json:"port"`
+	ServerKey string `json:"serverKey"`
+}
+
+// startPluginProcess launches the plugin binary named by config under the
+// local plugin install directory (~/.pact/plugins/<name>-<version>/),
+// reads its startup handshake off stdout to discover the port it bound,
+// and dials it over gRPC.
+func startPluginProcess(config PluginConfig) (*pluginProcess, error) {
+	dir, err := pluginInstallDir(config)
+	if err != nil {
+		return nil, err
+	}
+
+	entryPoint, err := readPluginManifestEntryPoint(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(filepath.Join(dir, entryPoint))
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), fmt.Sprintf("PACT_PLUGIN_DIR=%s", dir))
+	cmd.Stderr = os.Stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("unable to attach to plugin %s stdout: %w", config.Name, err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("unable to start plugin process %s: %w", entryPoint, err)
+	}
+
+	handshake, err := readPluginHandshake(stdout)
+	if err != nil {
+		_ = cmd.Process.Kill()
+		return nil, fmt.Errorf("plugin %s did not complete its startup handshake: %w", config.Name, err)
+	}
+
+	addr := fmt.Sprintf("127.0.0.1:%d", handshake.Port)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := grpc.DialContext(ctx, addr, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		_ = cmd.Process.Kill()
+		return nil, fmt.Errorf("unable to dial plugin %s at %s: %w", config.Name, addr, err)
+	}
+
+	return &pluginProcess{
+		config: config,
+		addr:   addr,
+		cmd:    cmd,
+		conn:   conn,
+	}, nil
+}
+
+// pluginInstallDir returns the directory a plugin is expected to be
+// installed in, following the layout of the Pact plugin CLI
+// (~/.pact/plugins/<name>-<version>/).
+func pluginInstallDir(config PluginConfig) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to determine plugin install directory: %w", err)
+	}
+
+	return filepath.Join(home, ".pact", "plugins", fmt.Sprintf("%s-%s", config.Name, config.Version)), nil
+}
+
+// pluginManifest is the pact-plugin.json file shipped alongside a plugin,
+// naming the executable to launch.
+type pluginManifest struct {
+	EntryPoint string `json:"entryPoint"`
+}
+
+func readPluginManifestEntryPoint(dir string) (string, error) {
+	f, err := os.Open(filepath.Join(dir, "pact-plugin.json"))
+	if err != nil {
+		return "", fmt.Errorf("unable to read plugin manifest in %s: %w", dir, err)
+	}
+	defer f.Close()
+
+	var manifest pluginManifest
+	if err := json.NewDecoder(f).Decode(&manifest); err != nil {
+		return "", fmt.Errorf("malformed plugin manifest in %s: %w", dir, err)
+	}
+
+	if manifest.EntryPoint == "" {
+		return "", fmt.Errorf("plugin manifest in %s is missing an entryPoint", dir)
+	}
+
+	return manifest.EntryPoint, nil
+}
+
+// readPluginHandshake reads the single line of JSON a plugin writes to
+// stdout on startup announcing the port (and server key) it is listening
+// on for the InitPlugin/ConfigureInteraction gRPC calls.
+func readPluginHandshake(stdout io.Reader) (*pluginHandshake, error) {
+	scanner := bufio.NewScanner(stdout)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("plugin exited before announcing a port")
+	}
+
+	var h pluginHandshake
+	if err := json.Unmarshal(scanner.Bytes(), &h); err != nil {
+		return nil, fmt.Errorf("unable to parse plugin handshake line %q: %w", scanner.Text(), err)
+	}
+
+	return &h, nil
+}
+
+// initPlugin performs the InitPlugin gRPC call against the running plugin
+// process and translates its reply into the catalogue entries it
+// publishes (the content types it can match/generate).
+func (p *pluginProcess) initPlugin(req initPluginRequest) (*initPluginResponse, error) {
+	payload, err := structpb.NewStruct(map[string]interface{}{
+		"implementation": req.ImplementationName,
+		"version":        req.ImplementationVersion,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	reply := &structpb.Struct{}
+	if err := p.conn.Invoke(context.Background(), "/io.pact.plugin.PactPlugin/InitPlugin", payload, reply); err != nil {
+		return nil, fmt.Errorf("InitPlugin call to %s failed: %w", p.config.Name, err)
+	}
+
+	return &initPluginResponse{Catalogue: parsePluginCatalogue(reply)}, nil
+}
+
+// parsePluginCatalogue reads the "catalogue" list out of an InitPlugin
+// reply, where each entry is a struct with "type", "key" and
+// "content-types" fields.
+func parsePluginCatalogue(reply *structpb.Struct) []pluginCatalogueEntry {
+	var entries []pluginCatalogueEntry
+
+	list, ok := reply.Fields["catalogue"]
+	if !ok {
+		return entries
+	}
+
+	for _, v := range list.GetListValue().GetValues() {
+		s := v.GetStructValue()
+		if s == nil {
+			continue
+		}
+
+		entry := pluginCatalogueEntry{
+			entryType: s.Fields["type"].GetStringValue(),
+			key:       s.Fields["key"].GetStringValue(),
+		}
+
+		for _, ct := range s.Fields["content-types"].GetListValue().GetValues() {
+			entry.contentTypes = append(entry.contentTypes, ct.GetStringValue())
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// configureInteraction performs the ConfigureInteraction gRPC call,
+// handing the plugin-described contents to the plugin and translating its
+// reply into the body bytes, matching rules and generators to attach to
+// the interaction.
+func (p *pluginProcess) configureInteraction(req configureInteractionRequest) (*configureInteractionResponse, error) {
+	contents, err := pluginValue(req.Contents)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s was given contents that can't be represented as a plugin payload: %w", p.config.Name, err)
+	}
+
+	payload, err := structpb.NewStruct(map[string]interface{}{
+		"contentType": req.ContentType,
+	})
+	if err != nil {
+		return nil, err
+	}
+	payload.Fields["contents"] = contents
+
+	reply := &structpb.Struct{}
+	if err := p.conn.Invoke(context.Background(), "/io.pact.plugin.PactPlugin/ConfigureInteraction", payload, reply); err != nil {
+		return nil, fmt.Errorf("ConfigureInteraction call to %s failed: %w", p.config.Name, err)
+	}
+
+	res := &configureInteractionResponse{
+		ContentType:       reply.Fields["contentType"].GetStringValue(),
+		InteractionMarkup: reply.Fields["interactionMarkup"].GetStringValue(),
+	}
+
+	if body := reply.Fields["contents"].GetStringValue(); body != "" {
+		res.Contents = []byte(body)
+	}
+
+	if rules := reply.Fields["rules"].GetStructValue(); rules != nil {
+		res.Rules = rules.AsMap()
+	}
+
+	if generators := reply.Fields["generators"].GetStructValue(); generators != nil {
+		res.Generators = generators.AsMap()
+	}
+
+	return res, nil
+}
+
+// pluginValue converts a Fields map (which, per the doc comment on
+// plugins/protobuf.MessageType.Fields, "may be plain Go values or pact matchers") into a
+// structpb.Value suitable for the ConfigureInteraction payload.
+// structpb.NewValue only understands plain Go values and rejects anything
+// else with an "invalid type" error, so a matchers.Matcher embedded in the
+// contents is round-tripped through encoding/json first - the same
+// MarshalJSON a matcher already implements to turn itself into its
+// "pact:matcher:type" rule definition when a JSON body is written to the
+// pact file - to arrive at a plain value structpb.NewValue can consume.
+func pluginValue(contents interface{}) (*structpb.Value, error) {
+	raw, err := json.Marshal(contents)
+	if err != nil {
+		return nil, err
+	}
+
+	var plain interface{}
+	if err := json.Unmarshal(raw, &plain); err != nil {
+		return nil, err
+	}
+
+	return structpb.NewValue(plain)
+}
+
+// stop terminates the plugin process and releases its gRPC connection.
+func (p *pluginProcess) stop() {
+	if p.conn != nil {
+		_ = p.conn.Close()
+	}
+
+	if p.cmd != nil && p.cmd.Process != nil {
+		_ = p.cmd.Process.Kill()
+		_ = p.cmd.Wait()
+	}
+}
@@ -0,0 +1,123 @@
+package consumer
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+)
+
+// StateHandlerFunc implements the setup/teardown for a single named
+// provider state, run entirely inside the consumer test process. setup is
+// true when the interaction using this state is about to be served and
+// false once it has completed. Values returned from the setup call are
+// made available to that interaction's FromProviderState matchers, e.g. an
+// id assigned by a "user exists" handler can be substituted into the
+// outgoing mock response at request time.
+type StateHandlerFunc func(setup bool, params map[string]interface{}) (map[string]interface{}, error)
+
+// stateChangeRequest is the payload the mock server posts to the callback
+// server for each Given() state attached to the interaction it is about to
+// serve (setup) or has just served (teardown).
+type stateChangeRequest struct {
+	State  string                 `json:"state"`
+	Params map[string]interface{} `json:"params"`
+	Action string                 `json:"action"` // "setup" or "teardown"
+}
+
+// stateHandlerServer is the consumer-side analogue of the provider
+// verification state change callback: the mock server calls back into this
+// process before and after each interaction so a StateHandlerFunc can
+// reconfigure state, without the user having to hand-wire multiple
+// interactions to simulate one piece of stateful behaviour.
+//
+// The full round trip has three parts, two of which are implemented here
+// and one of which belongs to the native mock server:
+//  1. config.StateHandlers is turned into a running callback server whose
+//     URL is handed to the native provider - done in NewV2Pact/NewV4Pact.
+//  2. An interaction names its state (and, via GivenWithParameter, the
+//     params the callback is invoked with) - done by
+//     UnconfiguredV2Interaction/UnconfiguredV4Interaction.Given/GivenWithParameter.
+//  3. Invoking this server once per interaction and substituting its
+//     response into that interaction's FromProviderState generators at
+//     request time is the native mock server's job, the same way it
+//     already drives the equivalent provider-side callback.
+//
+// This file covers (1) and the server itself (request in, handler
+// dispatch, JSON values out - covered by state_handlers_test.go); (3) has
+// no Go-side surface to test without the native mock server this fragment
+// doesn't include.
+type stateHandlerServer struct {
+	handlers map[string]StateHandlerFunc
+	server   *http.Server
+	listener net.Listener
+}
+
+// newStateHandlerServer starts a callback server on a random local port
+// that the mock server will invoke before and after serving an interaction
+// whose Given() state has a matching handler. Returns a nil server (and no
+// error) when there are no handlers to serve, so callers can skip wiring it
+// up entirely.
+func newStateHandlerServer(handlers map[string]StateHandlerFunc) (*stateHandlerServer, error) {
+	if len(handlers) == 0 {
+		return nil, nil
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("unable to start provider state callback server: %w", err)
+	}
+
+	s := &stateHandlerServer{handlers: handlers, listener: lis}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handle)
+	s.server = &http.Server{Handler: mux}
+
+	go func() {
+		if err := s.server.Serve(lis); err != nil && err != http.ErrServerClosed {
+			log.Printf("[ERROR] provider state callback server: %v", err)
+		}
+	}()
+
+	return s, nil
+}
+
+// URL returns the address the native mock server should be configured with
+// as its state change callback URL.
+func (s *stateHandlerServer) URL() string {
+	return fmt.Sprintf("http://%s/", s.listener.Addr().String())
+}
+
+func (s *stateHandlerServer) handle(w http.ResponseWriter, r *http.Request) {
+	var req stateChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	handler, ok := s.handlers[req.State]
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{})
+		return
+	}
+
+	values, err := handler(req.Action == "setup", req.Params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(values)
+}
+
+// Close stops the callback server. Safe to call on a nil *stateHandlerServer.
+func (s *stateHandlerServer) Close() error {
+	if s == nil || s.server == nil {
+		return nil
+	}
+
+	return s.server.Close()
+}
@@ -0,0 +1,115 @@
+package consumer
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestStateHandlerServerRoundTrip(t *testing.T) {
+	var gotSetup []bool
+	var gotParams []map[string]interface{}
+
+	server, err := newStateHandlerServer(map[string]StateHandlerFunc{
+		"User foo exists": func(setup bool, params map[string]interface{}) (map[string]interface{}, error) {
+			gotSetup = append(gotSetup, setup)
+			gotParams = append(gotParams, params)
+
+			return map[string]interface{}{"id": "abc123"}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("unable to start state handler server: %v", err)
+	}
+	defer server.Close()
+
+	post := func(action string) map[string]interface{} {
+		body, _ := json.Marshal(stateChangeRequest{
+			State:  "User foo exists",
+			Params: map[string]interface{}{"id": "foo"},
+			Action: action,
+		})
+
+		resp, err := http.Post(server.URL(), "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST %s failed: %v", action, err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST %s returned status %d", action, resp.StatusCode)
+		}
+
+		var values map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&values); err != nil {
+			t.Fatalf("unable to decode response for %s: %v", action, err)
+		}
+
+		return values
+	}
+
+	setupValues := post("setup")
+	if setupValues["id"] != "abc123" {
+		t.Fatalf("setup call returned %v, want id=abc123", setupValues)
+	}
+
+	teardownValues := post("teardown")
+	if teardownValues["id"] != "abc123" {
+		t.Fatalf("teardown call returned %v, want id=abc123", teardownValues)
+	}
+
+	if len(gotSetup) != 2 || gotSetup[0] != true || gotSetup[1] != false {
+		t.Fatalf("handler saw setup flags %v, want [true false]", gotSetup)
+	}
+
+	for i, params := range gotParams {
+		if params["id"] != "foo" {
+			t.Fatalf("call %d saw params %v, want id=foo", i, params)
+		}
+	}
+}
+
+func TestStateHandlerServerUnknownStateReturnsEmptyValues(t *testing.T) {
+	server, err := newStateHandlerServer(map[string]StateHandlerFunc{
+		"known state": func(setup bool, params map[string]interface{}) (map[string]interface{}, error) {
+			return map[string]interface{}{"id": "abc123"}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("unable to start state handler server: %v", err)
+	}
+	defer server.Close()
+
+	body, _ := json.Marshal(stateChangeRequest{State: "unrelated state", Action: "setup"})
+
+	resp, err := http.Post(server.URL(), "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var values map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&values); err != nil {
+		t.Fatalf("unable to decode response: %v", err)
+	}
+
+	if len(values) != 0 {
+		t.Fatalf("got values %v for a state with no handler, want empty", values)
+	}
+}
+
+func TestNewStateHandlerServerNilWhenNoHandlers(t *testing.T) {
+	server, err := newStateHandlerServer(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if server != nil {
+		t.Fatalf("expected a nil server when there are no handlers, got %+v", server)
+	}
+
+	if err := server.Close(); err != nil {
+		t.Fatalf("Close on a nil server should be a no-op, got: %v", err)
+	}
+}
@@ -0,0 +1,178 @@
+// StreamBody/StreamBodyMatch are implemented on the V2 and V4 HTTP
+// interaction builders (http_v2.go, http_v4.go). This tree has no
+// standalone V3 HTTP interaction builder type to extend - V2 and V4 are
+// the only generations of the synchronous HTTP builder present here - so
+// V4, the newest one, is where the "V3 equivalent" behaviour lives.
+package consumer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// DefaultStreamBodySidecarThreshold is the body size, in bytes, above which
+// StreamBody persists the payload to a sidecar file next to the pact file
+// instead of inlining it as a JSON string, to keep large contracts (file
+// uploads, NDJSON exports, etc.) out of the pact file itself.
+const DefaultStreamBodySidecarThreshold = 1024 * 1024 // 1MB
+
+// StreamBodyOption configures how a streamed body is recorded.
+type StreamBodyOption func(*streamBodyConfig)
+
+type streamBodyConfig struct {
+	sidecarThreshold int
+	matchLineByLine  bool
+}
+
+// WithSidecarThreshold overrides DefaultStreamBodySidecarThreshold for a
+// single StreamBody/StreamBodyMatch call.
+func WithSidecarThreshold(bytes int) StreamBodyOption {
+	return func(c *streamBodyConfig) {
+		c.sidecarThreshold = bytes
+	}
+}
+
+func newStreamBodyConfig(opts ...StreamBodyOption) *streamBodyConfig {
+	c := &streamBodyConfig{sidecarThreshold: DefaultStreamBodySidecarThreshold}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// StreamBody describes an expected request body by reading it from r, for
+// large payload contracts (e.g. a multi-megabyte file or an NDJSON
+// export), instead of requiring the caller to hold the whole fixture in
+// memory before calling this method. Bodies larger than the configured
+// sidecar threshold are persisted to a file alongside the pact file
+// (WithRequestBodySidecar) rather than inlined into the pact JSON
+// (WithRequestBody); how the resulting interaction is actually served to,
+// and compared against, the system under test at verification time - e.g.
+// chunked Transfer-Encoding, or reading a sidecar file back off disk - is
+// the native mock server's job, the same as every other *Body method here.
+func (i *V2InteractionWithRequestBuilder) StreamBody(contentType string, r io.Reader, opts ...StreamBodyOption) *V2InteractionWithRequestBuilder {
+	body, sidecar, err := bufferStreamedBody(r, newStreamBodyConfig(opts...))
+	if err != nil {
+		panic(fmt.Errorf("unable to read streamed request body: %w", err))
+	}
+
+	if sidecar != "" {
+		i.interaction.interaction.WithRequestBodySidecar(contentType, sidecar)
+	} else {
+		i.interaction.interaction.WithRequestBody(contentType, body)
+	}
+
+	return i
+}
+
+// StreamBodyMatch is the NDJSON-aware variant of StreamBody: it tells the
+// native mock server (via WithRequestBodyLineMatch/WithRequestBodySidecarLineMatch)
+// to compare the actual request body line-by-line at verification time
+// instead of as a single byte-for-byte comparison, so reordered or
+// re-chunked lines still match.
+func (i *V2InteractionWithRequestBuilder) StreamBodyMatch(contentType string, r io.Reader, opts ...StreamBodyOption) *V2InteractionWithRequestBuilder {
+	c := newStreamBodyConfig(opts...)
+	c.matchLineByLine = true
+
+	body, sidecar, err := bufferStreamedBody(r, c)
+	if err != nil {
+		panic(fmt.Errorf("unable to read streamed request body: %w", err))
+	}
+
+	if sidecar != "" {
+		i.interaction.interaction.WithRequestBodySidecarLineMatch(contentType, sidecar)
+	} else {
+		i.interaction.interaction.WithRequestBodyLineMatch(contentType, body)
+	}
+
+	return i
+}
+
+// StreamBody describes a response body by reading it from r rather than
+// requiring the caller to hold the whole fixture in memory before calling
+// this method - see the request-side StreamBody for the sidecar behaviour
+// and the native/Go scope split.
+func (i *V2InteractionWithResponseBuilder) StreamBody(contentType string, r io.Reader, opts ...StreamBodyOption) *V2InteractionWithResponseBuilder {
+	body, sidecar, err := bufferStreamedBody(r, newStreamBodyConfig(opts...))
+	if err != nil {
+		panic(fmt.Errorf("unable to read streamed response body: %w", err))
+	}
+
+	if sidecar != "" {
+		i.interaction.interaction.WithResponseBodySidecar(contentType, sidecar)
+	} else {
+		i.interaction.interaction.WithResponseBody(contentType, body)
+	}
+
+	return i
+}
+
+// StreamBodyMatch is the NDJSON-aware variant of StreamBody for responses -
+// see V2InteractionWithRequestBuilder.StreamBodyMatch for the line-by-line
+// comparison semantics, which apply identically when the body is replayed
+// during provider verification.
+func (i *V2InteractionWithResponseBuilder) StreamBodyMatch(contentType string, r io.Reader, opts ...StreamBodyOption) *V2InteractionWithResponseBuilder {
+	c := newStreamBodyConfig(opts...)
+	c.matchLineByLine = true
+
+	body, sidecar, err := bufferStreamedBody(r, c)
+	if err != nil {
+		panic(fmt.Errorf("unable to read streamed response body: %w", err))
+	}
+
+	if sidecar != "" {
+		i.interaction.interaction.WithResponseBodySidecarLineMatch(contentType, sidecar)
+	} else {
+		i.interaction.interaction.WithResponseBodyLineMatch(contentType, body)
+	}
+
+	return i
+}
+
+// bufferStreamedBody reads r fully, and if it exceeds the configured
+// sidecar threshold, spills it to a temporary sidecar file instead of
+// returning it in memory. It returns either the in-memory body or the path
+// to the sidecar file, never both.
+func bufferStreamedBody(r io.Reader, c *streamBodyConfig) (body []byte, sidecarPath string, err error) {
+	var buf bytes.Buffer
+	limited := io.LimitReader(r, int64(c.sidecarThreshold)+1)
+
+	if _, err := buf.ReadFrom(limited); err != nil {
+		return nil, "", err
+	}
+
+	if buf.Len() <= c.sidecarThreshold {
+		return buf.Bytes(), "", nil
+	}
+
+	f, err := newPactSidecarFile()
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return nil, "", err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		return nil, "", err
+	}
+	if err := w.Flush(); err != nil {
+		return nil, "", err
+	}
+
+	return nil, f.Name(), nil
+}
+
+// newPactSidecarFile creates a temporary file to hold an oversized streamed
+// body. The native interaction is responsible for copying it alongside the
+// final pact file when the pact is written, and referencing it from the
+// pact JSON rather than inlining its contents.
+func newPactSidecarFile() (*os.File, error) {
+	return os.CreateTemp("", "pact-sidecar-*")
+}
@@ -0,0 +1,65 @@
+package consumer
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBufferStreamedBodySmallBodyStaysInMemory(t *testing.T) {
+	body, sidecar, err := bufferStreamedBody(strings.NewReader("hello world"), newStreamBodyConfig())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if sidecar != "" {
+		t.Fatalf("expected no sidecar file, got %q", sidecar)
+	}
+
+	if string(body) != "hello world" {
+		t.Fatalf("got body %q, want %q", body, "hello world")
+	}
+}
+
+func TestBufferStreamedBodyLargeBodySpillsToSidecar(t *testing.T) {
+	large := bytes.Repeat([]byte("x"), 64)
+
+	body, sidecar, err := bufferStreamedBody(bytes.NewReader(large), newStreamBodyConfig(WithSidecarThreshold(16)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if body != nil {
+		t.Fatalf("expected no in-memory body when spilling to a sidecar, got %d bytes", len(body))
+	}
+
+	if sidecar == "" {
+		t.Fatal("expected a sidecar file path, got none")
+	}
+	defer os.Remove(sidecar)
+
+	got, err := os.ReadFile(sidecar)
+	if err != nil {
+		t.Fatalf("unable to read sidecar file: %v", err)
+	}
+
+	if !bytes.Equal(got, large) {
+		t.Fatalf("sidecar file contents did not match: got %d bytes, want %d bytes", len(got), len(large))
+	}
+}
+
+func TestBufferStreamedBodyExactlyAtThresholdStaysInMemory(t *testing.T) {
+	body, sidecar, err := bufferStreamedBody(strings.NewReader("0123456789"), newStreamBodyConfig(WithSidecarThreshold(10)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if sidecar != "" {
+		t.Fatalf("expected no sidecar file at exactly the threshold, got %q", sidecar)
+	}
+
+	if string(body) != "0123456789" {
+		t.Fatalf("got body %q, want %q", body, "0123456789")
+	}
+}
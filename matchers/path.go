@@ -0,0 +1,208 @@
+package matchers
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PathRule pairs a JSONPath-like expression with the matching rule that
+// should apply to whatever value it resolves to, for use with
+// WithMatchingRule and V2InteractionWithRequestBuilder.MatchingRules /
+// V2InteractionWithResponseBuilder.MatchingRules. This lets a body be kept
+// as a plain JSON fixture while matching intent is expressed separately,
+// the same split pact-rust's DocPath builders use for add_matching_rule.
+type PathRule struct {
+	Path string
+	Rule Matcher
+}
+
+// WithMatchingRule attaches rule to the value found at path. path is a
+// small JSONPath-like expression supporting:
+//
+//	$          root
+//	.name      child by name
+//	['name']   child by name (for names that aren't valid identifiers)
+//	[n]        child by index
+//	[*]        wildcard over all children of an array
+//	..         deep scan (matches at any depth)
+//
+// e.g. "$.items[*].id" or "$.user.address['postcode']".
+func WithMatchingRule(path string, rule Matcher) PathRule {
+	return PathRule{Path: path, Rule: rule}
+}
+
+// canonicalPath parses a JSONPath-like expression and renders it back out
+// in the canonical dotted form the pact FFI expects (e.g. "$.items[*].id"),
+// validating the expression along the way.
+func canonicalPath(expr string) (string, error) {
+	segments, err := parsePath(expr)
+	if err != nil {
+		return "", fmt.Errorf("invalid matching rule path %q: %w", expr, err)
+	}
+
+	var b strings.Builder
+	b.WriteString("$")
+
+	for idx, s := range segments {
+		switch s.kind {
+		case pathSegmentChild:
+			// A child immediately following a deep scan (e.g. "$..id") is
+			// already separated by the scan's own "..", so it doesn't get
+			// a second "." in front of it.
+			if idx == 0 || segments[idx-1].kind != pathSegmentDeep {
+				b.WriteString(".")
+			}
+			b.WriteString(s.name)
+		case pathSegmentIndex:
+			fmt.Fprintf(&b, "[%d]", s.index)
+		case pathSegmentWildcard:
+			b.WriteString("[*]")
+		case pathSegmentDeep:
+			b.WriteString("..")
+		}
+	}
+
+	return b.String(), nil
+}
+
+type pathSegmentKind int
+
+const (
+	pathSegmentChild pathSegmentKind = iota
+	pathSegmentIndex
+	pathSegmentWildcard
+	pathSegmentDeep
+)
+
+type pathSegmentToken struct {
+	kind  pathSegmentKind
+	name  string
+	index int
+}
+
+// parsePath tokenises a JSONPath-like expression into its segments. It
+// intentionally only supports the small subset of JSONPath pact's matching
+// rules need: root, named/bracketed child, numeric index, wildcard and
+// deep scan.
+func parsePath(expr string) ([]pathSegmentToken, error) {
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("path must start with '$'")
+	}
+
+	rest := expr[1:]
+	var segments []pathSegmentToken
+
+	for len(rest) > 0 {
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			segments = append(segments, pathSegmentToken{kind: pathSegmentDeep})
+			rest = rest[2:]
+
+			// A deep scan is commonly followed directly by a bare field
+			// name rather than another '.' or '[' (e.g. "$..id"); consume
+			// it as a child of the scan instead of erroring out.
+			if len(rest) > 0 && rest[0] != '.' && rest[0] != '[' {
+				name, remainder := takeIdentifier(rest)
+				if name == "" {
+					return nil, fmt.Errorf("expected a field name after '..'")
+				}
+				segments = append(segments, pathSegmentToken{kind: pathSegmentChild, name: name})
+				rest = remainder
+			}
+
+		case strings.HasPrefix(rest, "."):
+			rest = rest[1:]
+			name, remainder := takeIdentifier(rest)
+			if name == "" {
+				return nil, fmt.Errorf("expected a field name after '.'")
+			}
+			segments = append(segments, pathSegmentToken{kind: pathSegmentChild, name: name})
+			rest = remainder
+
+		case strings.HasPrefix(rest, "[*]"):
+			segments = append(segments, pathSegmentToken{kind: pathSegmentWildcard})
+			rest = rest[3:]
+
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated '['")
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+
+			switch {
+			case strings.HasPrefix(inner, "'") && strings.HasSuffix(inner, "'"):
+				segments = append(segments, pathSegmentToken{kind: pathSegmentChild, name: strings.Trim(inner, "'")})
+			case strings.HasPrefix(inner, `"`) && strings.HasSuffix(inner, `"`):
+				segments = append(segments, pathSegmentToken{kind: pathSegmentChild, name: strings.Trim(inner, `"`)})
+			default:
+				idx, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid index %q", inner)
+				}
+				segments = append(segments, pathSegmentToken{kind: pathSegmentIndex, index: idx})
+			}
+
+		default:
+			return nil, fmt.Errorf("unexpected characters %q", rest)
+		}
+	}
+
+	return segments, nil
+}
+
+func takeIdentifier(s string) (string, string) {
+	for i, r := range s {
+		if r == '.' || r == '[' {
+			return s[:i], s[i:]
+		}
+	}
+
+	return s, ""
+}
+
+// MergeMatchingRules folds rules into existing, keyed by their canonical
+// path, so that a later rule for the same path overrides an earlier one in
+// existing or earlier in rules. Builder methods that accept PathRules (e.g.
+// V2InteractionWithRequestBuilder.MatchingRules) pass as existing only the
+// rules from earlier MatchingRules calls on the same builder value; rules
+// from matchers written inline in the body are not part of existing.
+func MergeMatchingRules(existing map[string]Matcher, rules []PathRule) (map[string]Matcher, error) {
+	merged := make(map[string]Matcher, len(existing)+len(rules))
+	for k, v := range existing {
+		merged[k] = v
+	}
+
+	for _, r := range rules {
+		path, err := canonicalPath(r.Path)
+		if err != nil {
+			return nil, err
+		}
+		merged[path] = r.Rule
+	}
+
+	return merged, nil
+}
+
+// RulesFromMatchingRules turns a path-keyed rule set (as produced by
+// MergeMatchingRules) back into the []PathRule shape
+// WithRequestMatchingRules/WithResponseMatchingRules already accept,
+// ordered by path so the resulting call is deterministic. Paths stored in
+// rules are already canonical, so WithMatchingRule is given them as-is.
+func RulesFromMatchingRules(rules map[string]Matcher) []PathRule {
+	paths := make([]string, 0, len(rules))
+	for path := range rules {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	out := make([]PathRule, 0, len(paths))
+	for _, path := range paths {
+		out = append(out, WithMatchingRule(path, rules[path]))
+	}
+
+	return out
+}
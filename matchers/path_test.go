@@ -0,0 +1,46 @@
+package matchers
+
+import "testing"
+
+func TestCanonicalPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{name: "root", path: "$", want: "$"},
+		{name: "child", path: "$.user.name", want: "$.user.name"},
+		{name: "bracketed child", path: "$.user['name']", want: "$.user.name"},
+		{name: "double-quoted bracketed child", path: `$.user["name"]`, want: "$.user.name"},
+		{name: "index", path: "$.items[0].id", want: "$.items[0].id"},
+		{name: "wildcard", path: "$.items[*].id", want: "$.items[*].id"},
+		{name: "deep scan with bare name", path: "$..id", want: "$..id"},
+		{name: "deep scan mid path", path: "$.foo..bar", want: "$.foo..bar"},
+		{name: "missing root", path: "user.name", wantErr: true},
+		{name: "dangling dot", path: "$.", wantErr: true},
+		{name: "unterminated bracket", path: "$.items[0", wantErr: true},
+		{name: "non-numeric index", path: "$.items[abc]", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := canonicalPath(tt.path)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("canonicalPath(%q) = %q, want error", tt.path, got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("canonicalPath(%q) returned unexpected error: %v", tt.path, err)
+			}
+
+			if got != tt.want {
+				t.Fatalf("canonicalPath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
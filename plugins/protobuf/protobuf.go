@@ -0,0 +1,39 @@
+// Package protobuf is a reference adapter demonstrating how to describe
+// Protobuf message content to the Pact protobuf plugin, for use with
+// consumer.V4HTTPMockProvider's WithPluginRequestContent /
+// WithPluginResponseContent builders.
+//
+// The plugin itself (not this package) is responsible for compiling the
+// supplied .proto file and encoding/decoding messages; this package only
+// builds the description the plugin's ContentMatcher/ContentGenerator
+// expects to receive over the plugin gRPC protocol.
+package protobuf
+
+// MessageType describes a single Protobuf message, sourced from a .proto
+// file, that a plugin-backed interaction should encode or decode.
+type MessageType struct {
+	// Proto is the path to the .proto file containing the message
+	// definition.
+	Proto string
+
+	// MessageName is the fully qualified message name within Proto, e.g.
+	// "routeguide.Feature".
+	MessageName string
+
+	// Fields holds the example values for the message, keyed by field
+	// name. Values may be plain Go values or pact matchers, mirroring the
+	// way JSON bodies are described elsewhere in pact-go.
+	Fields map[string]interface{}
+}
+
+// Content builds the `contents` value expected by
+// WithPluginRequestContent/WithPluginResponseContent: a description the
+// protobuf plugin can turn into an encoded message plus matching rules.
+func Content(m MessageType) map[string]interface{} {
+	return map[string]interface{}{
+		"pact:proto":        m.Proto,
+		"pact:message-type": m.MessageName,
+		"pact:content-type": "application/protobuf",
+		"fields":            m.Fields,
+	}
+}